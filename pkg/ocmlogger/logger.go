@@ -0,0 +1,508 @@
+// Package ocmlogger provides a small leveled, structured logger used across
+// OCM services. It wraps a fluent API (Contextual, With, CaptureSentryEvent,
+// AdditionalCallLevelSkips) so request handlers can attach ad-hoc key/value
+// data to a single log line without depending on a heavier external logging
+// framework. Output is pluggable: every log record is fanned out to the
+// Sink(s) registered with AddSink (JSON by default; see sink.go for the
+// text and Sentry adapters), each with its own minimum level. Below Info sit
+// Trace and Debug, gated by SetVerbosity (see verbosity.go) in addition to
+// each sink's MinLevel; SetVerbosity can enable them for one noisy module at
+// a time without turning them on process-wide.
+package ocmlogger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+
+	sentry "github.com/getsentry/sentry-go"
+)
+
+// Level identifies the severity of a log record.
+type Level int
+
+// The numeric values of LevelInfo, LevelWarning, LevelError and LevelFatal
+// are part of this package's wire/config compatibility surface (anything
+// that compared, persisted, or serialized the raw int before Trace/Debug
+// existed) and are therefore pinned explicitly rather than left to iota;
+// LevelTrace and LevelDebug were added below LevelInfo and given negative
+// values so ordering comparisons (e.g. level >= someMinimum) still hold
+// without renumbering the pre-existing levels.
+const (
+	LevelTrace   Level = -2
+	LevelDebug   Level = -1
+	LevelInfo    Level = 0
+	LevelWarning Level = 1
+	LevelError   Level = 2
+	LevelFatal   Level = 3
+)
+
+// String renders the level the way it is emitted in the "level" field.
+func (l Level) String() string {
+	switch l {
+	case LevelTrace:
+		return "trace"
+	case LevelDebug:
+		return "debug"
+	case LevelInfo:
+		return "info"
+	case LevelWarning:
+		return "warning"
+	case LevelError:
+		return "error"
+	case LevelFatal:
+		return "fatal"
+	default:
+		return "unknown"
+	}
+}
+
+// MarshalJSON renders a Level the same way String does, so Entry.Level keeps
+// emitting e.g. "warning" rather than its underlying integer value.
+func (l Level) MarshalJSON() ([]byte, error) {
+	return json.Marshal(l.String())
+}
+
+// ExtraDataCallback extracts a value from a context.Context to be included,
+// under the name it was registered with, in every log record's "Extra"
+// object.
+type ExtraDataCallback func(ctx context.Context) any
+
+var (
+	extraDataCallbacksMu sync.RWMutex
+	extraDataCallbacks   = map[string]ExtraDataCallback{}
+)
+
+// SetOutput directs the default sink's JSON output to w. It is a thin shim
+// over AddSink kept for callers that only need a single writer; it preserves
+// whatever minimum level the default sink was already configured with, or
+// LevelWarning if there is no default sink yet. Production code normally
+// leaves the default of os.Stderr in place; tests use it to capture output,
+// which is also why, unlike the built-in default sink installed by init(),
+// the replacement this installs is not wrapped in NewBufferedSink: tests
+// that assert on output immediately after logging need that write to have
+// already happened. A caller directing production output at something slow
+// enough to need buffering should wrap w's sink itself, e.g. via
+// AddSink(defaultSinkName, NewBufferedSink(NewJSONSink(w, level), n)).
+func SetOutput(w io.Writer) {
+	level := LevelWarning
+	if s := getSink(defaultSinkName); s != nil {
+		level = s.MinLevel()
+	}
+	AddSink(defaultSinkName, NewJSONSink(w, level))
+}
+
+// SetMinLevel changes the minimum level emitted by the default sink. It is a
+// no-op if no default sink has been installed (e.g. via SetOutput or
+// AddSink(defaultSinkName, ...)).
+func SetMinLevel(l Level) {
+	if s, ok := getSink(defaultSinkName).(minLevelSetter); ok {
+		s.SetMinLevel(l)
+	}
+}
+
+// RegisterExtraDataCallback registers fn to be evaluated against the active
+// context on every log record; its result is added to the "Extra" object
+// under name. A nil fn is ignored.
+func RegisterExtraDataCallback(name string, fn ExtraDataCallback) {
+	if fn == nil {
+		return
+	}
+	extraDataCallbacksMu.Lock()
+	defer extraDataCallbacksMu.Unlock()
+	extraDataCallbacks[name] = fn
+}
+
+// RemoveExtraDataCallback removes a single callback previously registered
+// under name, leaving any others untouched. Removing a name that was never
+// registered is a no-op.
+func RemoveExtraDataCallback(name string) {
+	extraDataCallbacksMu.Lock()
+	defer extraDataCallbacksMu.Unlock()
+	delete(extraDataCallbacks, name)
+}
+
+// ClearExtraDataCallbacks removes every callback registered via
+// RegisterExtraDataCallback, including the built-in "ctx_cause" one; callers
+// that want to keep the latter should call EnableContextCauseExtra
+// afterwards.
+func ClearExtraDataCallbacks() {
+	extraDataCallbacksMu.Lock()
+	defer extraDataCallbacksMu.Unlock()
+	extraDataCallbacks = map[string]ExtraDataCallback{}
+}
+
+// ctxCauseExtraKey is the Extra field populated by the built-in context
+// cancellation callback below.
+const ctxCauseExtraKey = "ctx_cause"
+
+// contextCauseExtraCallback is the built-in ExtraDataCallback that surfaces
+// context.Cause(ctx) once a logger's context has been canceled or its
+// deadline has passed. context.Cause already falls back to ctx.Err() when no
+// cause was attached via context.WithCancelCause, so the raw
+// "context canceled" string is still available even without one. It stays
+// silent while the context is still live, so healthy log lines are not
+// cluttered by it.
+func contextCauseExtraCallback(ctx context.Context) any {
+	if ctx.Err() == nil {
+		return nil
+	}
+	if cause := context.Cause(ctx); cause != nil {
+		return cause.Error()
+	}
+	return nil
+}
+
+func init() {
+	RegisterExtraDataCallback(ctxCauseExtraKey, contextCauseExtraCallback)
+}
+
+// DisableContextCauseExtra opts out of the built-in "ctx_cause" extra field
+// registered during package initialization.
+func DisableContextCauseExtra() {
+	RemoveExtraDataCallback(ctxCauseExtraKey)
+}
+
+// EnableContextCauseExtra (re-)enables the "ctx_cause" extra field; it is on
+// by default.
+func EnableContextCauseExtra() {
+	RegisterExtraDataCallback(ctxCauseExtraKey, contextCauseExtraCallback)
+}
+
+func collectContextExtras(ctx context.Context) map[string]interface{} {
+	if ctx == nil {
+		return nil
+	}
+
+	extraDataCallbacksMu.RLock()
+	defer extraDataCallbacksMu.RUnlock()
+
+	if len(extraDataCallbacks) == 0 {
+		return nil
+	}
+
+	var extras map[string]interface{}
+	for name, fn := range extraDataCallbacks {
+		if fn == nil {
+			continue
+		}
+		if v := fn(ctx); v != nil {
+			if extras == nil {
+				extras = map[string]interface{}{}
+			}
+			extras[name] = v
+		}
+	}
+	return extras
+}
+
+// OCMLogger is the primary logging interface used across OCM services. It is
+// obtained via NewOCMLogger and is safe for concurrent use: every method
+// that customizes behavior (Contextual, CaptureSentryEvent,
+// AdditionalCallLevelSkips) returns an independent copy rather than mutating
+// the receiver.
+type OCMLogger interface {
+	// Trace logs a printf-style message at LevelTrace, the most verbose
+	// tier. Whether it is actually emitted is governed by SetVerbosity,
+	// not by a sink's MinLevel alone.
+	Trace(format string, args ...interface{})
+	// Debug logs a printf-style message at LevelDebug, gated the same way
+	// as Trace.
+	Debug(format string, args ...interface{})
+	// Info logs a printf-style message at LevelInfo.
+	Info(format string, args ...interface{})
+	// Warning logs a printf-style message at LevelWarning.
+	Warning(format string, args ...interface{})
+	// Error logs err at LevelError.
+	Error(err error)
+
+	// Contextual returns a logger that accepts structured key/value pairs
+	// instead of printf-style arguments.
+	Contextual() OCMLoggerContextual
+
+	// CaptureSentryEvent returns a copy of the logger that will (or will
+	// not) forward Error-level records to Sentry.
+	CaptureSentryEvent(capture bool) OCMLogger
+
+	// AdditionalCallLevelSkips returns a copy of the logger that skips n
+	// additional stack frames when attributing a log line to its caller.
+	AdditionalCallLevelSkips(skips int) OCMLogger
+
+	// With returns a child logger with keysAndValues permanently bound: every
+	// subsequent Info/Warning/Error/Contextual call on the child (or on any
+	// further descendant obtained via chained With calls) emits those pairs
+	// in "Extra" without the caller repeating them. A key bound by a later
+	// With call, or passed directly to a logging call, overrides one bound
+	// by an earlier With call.
+	With(keysAndValues ...interface{}) OCMLogger
+
+	// Sampled returns a copy of the logger that emits only the every-th
+	// record logged from its call site (the first call always emits).
+	// Suppressed records in between are counted and folded into the next
+	// emitted record as a "suppressed" Extra field, so the drop is visible
+	// rather than silent. It overrides any previous Sampled/RateLimited on
+	// the receiver.
+	Sampled(every int) OCMLogger
+
+	// RateLimited returns a copy of the logger that emits at most
+	// perSecond records per second from its call site, folding the count of
+	// anything suppressed within the prior window into the next emitted
+	// record's "suppressed" Extra field. It overrides any previous
+	// Sampled/RateLimited on the receiver.
+	RateLimited(perSecond int) OCMLogger
+}
+
+// OCMLoggerContextual is the structured variant of OCMLogger, returned by
+// OCMLogger.Contextual(). Every call accepts alternating key/value pairs
+// that are rendered into the record's "Extra" object.
+type OCMLoggerContextual interface {
+	Trace(message string, keysAndValues ...interface{})
+	Debug(message string, keysAndValues ...interface{})
+	Info(message string, keysAndValues ...interface{})
+	Warning(message string, keysAndValues ...interface{})
+	Error(err error, message string, keysAndValues ...interface{})
+}
+
+type ocmLogger struct {
+	ctx                      context.Context
+	captureSentryEvent       bool
+	additionalCallLevelSkips int
+	bound                    map[string]interface{}
+	sampleEvery              int
+	rateLimitPerSecond       int
+}
+
+// NewOCMLogger returns an OCMLogger bound to ctx. The context is consulted on
+// every log call for any callbacks registered via RegisterExtraDataCallback.
+func NewOCMLogger(ctx context.Context) OCMLogger {
+	return ocmLogger{ctx: ctx}
+}
+
+func (l ocmLogger) CaptureSentryEvent(capture bool) OCMLogger {
+	l.captureSentryEvent = capture
+	return l
+}
+
+func (l ocmLogger) AdditionalCallLevelSkips(skips int) OCMLogger {
+	l.additionalCallLevelSkips = skips
+	return l
+}
+
+// With returns a copy of l with keysAndValues merged into its bound fields.
+// l itself (and any other logger derived from it) is left untouched, since
+// the bound map is never mutated in place, only replaced.
+func (l ocmLogger) With(keysAndValues ...interface{}) OCMLogger {
+	added := keysAndValuesToMap(keysAndValues)
+	if len(added) == 0 {
+		return l
+	}
+
+	bound := make(map[string]interface{}, len(l.bound)+len(added))
+	for k, v := range l.bound {
+		bound[k] = v
+	}
+	for k, v := range added {
+		bound[k] = v
+	}
+	l.bound = bound
+	return l
+}
+
+// Sampled returns a copy of l that emits only 1-in-every record logged from
+// its call site. every < 1 is treated as 1 (emit everything).
+func (l ocmLogger) Sampled(every int) OCMLogger {
+	if every < 1 {
+		every = 1
+	}
+	l.sampleEvery = every
+	l.rateLimitPerSecond = 0
+	return l
+}
+
+// RateLimited returns a copy of l that emits at most perSecond records per
+// second from its call site. perSecond < 1 is treated as 1.
+func (l ocmLogger) RateLimited(perSecond int) OCMLogger {
+	if perSecond < 1 {
+		perSecond = 1
+	}
+	l.rateLimitPerSecond = perSecond
+	l.sampleEvery = 0
+	return l
+}
+
+func (l ocmLogger) Trace(format string, args ...interface{}) {
+	l.log(LevelTrace, nil, fmt.Sprintf(format, args...), nil)
+}
+
+func (l ocmLogger) Debug(format string, args ...interface{}) {
+	l.log(LevelDebug, nil, fmt.Sprintf(format, args...), nil)
+}
+
+func (l ocmLogger) Info(format string, args ...interface{}) {
+	l.log(LevelInfo, nil, fmt.Sprintf(format, args...), nil)
+}
+
+func (l ocmLogger) Warning(format string, args ...interface{}) {
+	l.log(LevelWarning, nil, fmt.Sprintf(format, args...), nil)
+}
+
+func (l ocmLogger) Error(err error) {
+	message := ""
+	if err != nil {
+		message = err.Error()
+	}
+	l.log(LevelError, err, message, nil)
+}
+
+func (l ocmLogger) Contextual() OCMLoggerContextual {
+	return ocmLoggerContextual{ocmLogger: l}
+}
+
+type ocmLoggerContextual struct {
+	ocmLogger
+}
+
+func (l ocmLoggerContextual) Trace(message string, keysAndValues ...interface{}) {
+	l.log(LevelTrace, nil, message, keysAndValues)
+}
+
+func (l ocmLoggerContextual) Debug(message string, keysAndValues ...interface{}) {
+	l.log(LevelDebug, nil, message, keysAndValues)
+}
+
+func (l ocmLoggerContextual) Info(message string, keysAndValues ...interface{}) {
+	l.log(LevelInfo, nil, message, keysAndValues)
+}
+
+func (l ocmLoggerContextual) Warning(message string, keysAndValues ...interface{}) {
+	l.log(LevelWarning, nil, message, keysAndValues)
+}
+
+func (l ocmLoggerContextual) Error(err error, message string, keysAndValues ...interface{}) {
+	l.log(LevelError, err, message, keysAndValues)
+}
+
+func keysAndValuesToMap(keysAndValues []interface{}) map[string]interface{} {
+	if len(keysAndValues) == 0 {
+		return nil
+	}
+	extra := map[string]interface{}{}
+	for i := 0; i+1 < len(keysAndValues); i += 2 {
+		key := fmt.Sprintf("%v", keysAndValues[i])
+		extra[key] = keysAndValues[i+1]
+	}
+	return extra
+}
+
+// combineExtras layers maps left-to-right into a freshly allocated map,
+// later maps winning on key collision. None of the inputs are mutated, since
+// they may be shared with other loggers (e.g. a parent's bound fields).
+func combineExtras(maps ...map[string]interface{}) map[string]interface{} {
+	var out map[string]interface{}
+	for _, m := range maps {
+		for k, v := range m {
+			if out == nil {
+				out = make(map[string]interface{}, len(m))
+			}
+			out[k] = v
+		}
+	}
+	return out
+}
+
+func (l ocmLogger) log(level Level, err error, message string, keysAndValues []interface{}) {
+	if !verbosityAllows(level, l.additionalCallLevelSkips) {
+		return
+	}
+
+	extra := combineExtras(l.bound, collectContextExtras(l.ctx), keysAndValuesToMap(keysAndValues))
+
+	if l.sampleEvery > 0 || l.rateLimitPerSecond > 0 {
+		emit, suppressed := l.throttle(level)
+		if !emit {
+			return
+		}
+		if suppressed > 0 {
+			extra = combineExtras(extra, map[string]interface{}{"suppressed": suppressed})
+		}
+	}
+
+	e := Entry{
+		Level:         level,
+		Message:       message,
+		Extra:         extra,
+		err:           err,
+		captureSentry: l.captureSentryEvent,
+	}
+	if err != nil {
+		e.Error = err.Error()
+	}
+
+	publish(e)
+}
+
+// sentryCapture is captureSentryEvent, indirected through a package variable
+// so tests can substitute a spy and assert on what sentrySink forwards
+// without a real Sentry client configured.
+var sentryCapture = captureSentryEvent
+
+// captureSentryEvent forwards err (or, if nil, message) to Sentry along with
+// extra as scope extras. It is a no-op when no Sentry client has been
+// configured for the process.
+func captureSentryEvent(err error, message string, extra map[string]interface{}) {
+	hub := sentry.CurrentHub()
+	if hub == nil || hub.Client() == nil {
+		return
+	}
+
+	hub.WithScope(func(scope *sentry.Scope) {
+		for k, v := range extra {
+			scope.SetExtra(k, v)
+		}
+		if err != nil {
+			hub.CaptureException(err)
+			return
+		}
+		hub.CaptureMessage(message)
+	})
+}
+
+// ThreadSafeBytesBuffer is an io.Writer/io.Reader wrapper used by tests to
+// safely assert on log output produced by concurrent goroutines.
+type ThreadSafeBytesBuffer interface {
+	io.Writer
+	io.Reader
+	String() string
+}
+
+type threadSafeBytesBuffer struct {
+	mu  sync.Mutex
+	buf *bytes.Buffer
+}
+
+// WrapUnsafeWriterWithLocks wraps buf so concurrent writers can no longer
+// race on it.
+func WrapUnsafeWriterWithLocks(buf *bytes.Buffer) ThreadSafeBytesBuffer {
+	return &threadSafeBytesBuffer{buf: buf}
+}
+
+func (t *threadSafeBytesBuffer) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.buf.Write(p)
+}
+
+func (t *threadSafeBytesBuffer) Read(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.buf.Read(p)
+}
+
+func (t *threadSafeBytesBuffer) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.buf.String()
+}
@@ -6,10 +6,13 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/http/httptest"
 	"os"
+	"runtime"
 	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -91,6 +94,47 @@ var _ = Describe("logger.Extra", Label("logger"), func() {
 		})
 	})
 
+	Context("With() bound fields", func() {
+		It("includes bound fields on every call without repeating them", func() {
+			child := ulog.With("request_id", "req-1", "cluster_id", "cluster-1")
+
+			child.Warning("first")
+			child.Warning("second")
+
+			result := output.String()
+			Expect(strings.Count(result, "\"request_id\":\"req-1\"")).To(Equal(2))
+			Expect(strings.Count(result, "\"cluster_id\":\"cluster-1\"")).To(Equal(2))
+		})
+
+		It("merges bound fields from chained With calls", func() {
+			child := ulog.With("request_id", "req-1").With("cluster_id", "cluster-1")
+
+			child.Warning("message")
+
+			result := output.String()
+			Expect(result).To(ContainSubstring("\"request_id\":\"req-1\""))
+			Expect(result).To(ContainSubstring("\"cluster_id\":\"cluster-1\""))
+		})
+
+		It("lets a per-call key override a bound key of the same name", func() {
+			child := ulog.With("key1", 1)
+
+			child.Contextual().Warning("message", "key1", 2)
+
+			result := output.String()
+			Expect(result).To(ContainSubstring("\"key1\":2"))
+		})
+
+		It("does not leak bound fields back onto the parent logger", func() {
+			_ = ulog.With("request_id", "req-1")
+
+			ulog.Warning("message")
+
+			result := output.String()
+			Expect(result).NotTo(ContainSubstring("request_id"))
+		})
+	})
+
 	Context("complex/nested types", func() {
 		It("each will present in output from keysAndValues", func() {
 			headers1 := http.Header{}
@@ -160,6 +204,8 @@ var _ = Describe("logger.Extra", Label("logger"), func() {
 			ctx = context.WithValue(ctx, "tx_id", int64(123))
 			ulog = NewOCMLogger(ctx)
 
+			// DeferCleanup runs LIFO: registered first so ctx_cause is re-enabled after the clear below.
+			DeferCleanup(EnableContextCauseExtra)
 			DeferCleanup(ClearExtraDataCallbacks)
 		})
 
@@ -189,6 +235,478 @@ var _ = Describe("logger.Extra", Label("logger"), func() {
 			Expect(result).NotTo(ContainSubstring("\"Extra\""))
 		})
 	})
+
+	Context("context cancellation cause is added to output", func() {
+		It("is added once the context is canceled with a cause", func() {
+			ctx, cancel := context.WithCancelCause(context.Background())
+			cancel(fmt.Errorf("upstream aborted"))
+			ulog = NewOCMLogger(ctx)
+
+			ulog.Warning("warning")
+
+			result := output.String()
+			Expect(result).To(ContainSubstring("\"ctx_cause\":\"upstream aborted\""))
+		})
+
+		It("falls back to ctx.Err() when no cause was attached", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			ulog = NewOCMLogger(ctx)
+
+			ulog.Warning("warning")
+
+			result := output.String()
+			Expect(result).To(ContainSubstring("\"ctx_cause\":\"context canceled\""))
+		})
+
+		It("does not fire while the context is still live", func() {
+			ulog.Warning("warning")
+
+			result := output.String()
+			Expect(result).NotTo(ContainSubstring("ctx_cause"))
+		})
+
+		It("is silenced by DisableContextCauseExtra", func() {
+			DisableContextCauseExtra()
+			DeferCleanup(EnableContextCauseExtra)
+
+			ctx, cancel := context.WithCancelCause(context.Background())
+			cancel(fmt.Errorf("upstream aborted"))
+			ulog = NewOCMLogger(ctx)
+
+			ulog.Warning("warning")
+
+			result := output.String()
+			Expect(result).NotTo(ContainSubstring("ctx_cause"))
+		})
+	})
+})
+
+var _ = Describe("logger.Sink", Label("logger"), func() {
+	var ulog OCMLogger
+
+	BeforeEach(func() {
+		ulog = NewOCMLogger(context.Background())
+		DeferCleanup(func() {
+			SetOutput(os.Stderr)
+		})
+	})
+
+	Context("AddSink/RemoveSink", func() {
+		It("fans a record out to every sink meeting its own MinLevel", func() {
+			var jsonBuf, textBuf bytes.Buffer
+			AddSink("extra-json", NewJSONSink(WrapUnsafeWriterWithLocks(&jsonBuf), LevelWarning))
+			AddSink("extra-text", NewTextSink(WrapUnsafeWriterWithLocks(&textBuf), LevelInfo))
+			DeferCleanup(func() {
+				RemoveSink("extra-json")
+				RemoveSink("extra-text")
+			})
+
+			ulog.Info("quiet")
+			ulog.Warning("loud")
+
+			Expect(jsonBuf.String()).NotTo(ContainSubstring("quiet"))
+			Expect(jsonBuf.String()).To(ContainSubstring("\"message\":\"loud\""))
+			Expect(textBuf.String()).To(ContainSubstring("INFO: quiet"))
+			Expect(textBuf.String()).To(ContainSubstring("WARNING: loud"))
+		})
+
+		It("stops delivering to a sink once it is removed", func() {
+			var buf bytes.Buffer
+			AddSink("removable", NewJSONSink(WrapUnsafeWriterWithLocks(&buf), LevelWarning))
+
+			ulog.Warning("before removal")
+			RemoveSink("removable")
+			ulog.Warning("after removal")
+
+			Expect(buf.String()).To(ContainSubstring("before removal"))
+			Expect(buf.String()).NotTo(ContainSubstring("after removal"))
+		})
+	})
+
+	Context("AddSink/RemoveSink registration order", func() {
+		It("fans a record out in the order sinks were registered, not map iteration order", func() {
+			var order []string
+			record := func(name string) sinkFunc {
+				return sinkFunc{
+					minLevel: LevelWarning,
+					write: func(Entry) error {
+						order = append(order, name)
+						return nil
+					},
+				}
+			}
+			for _, name := range []string{"z-first", "a-second", "m-third"} {
+				AddSink(name, record(name))
+			}
+			DeferCleanup(func() {
+				RemoveSink("z-first")
+				RemoveSink("a-second")
+				RemoveSink("m-third")
+			})
+
+			ulog.Warning("message")
+
+			Expect(order).To(Equal([]string{"z-first", "a-second", "m-third"}))
+		})
+
+		It("keeps a replaced sink's original position instead of moving it to the end", func() {
+			var order []string
+			record := func(name string) sinkFunc {
+				return sinkFunc{
+					minLevel: LevelWarning,
+					write: func(Entry) error {
+						order = append(order, name)
+						return nil
+					},
+				}
+			}
+			AddSink("first", record("first"))
+			AddSink("second", record("second"))
+			DeferCleanup(func() {
+				RemoveSink("first")
+				RemoveSink("second")
+			})
+
+			AddSink("first", record("first-replaced")) // same name, registered first
+
+			ulog.Warning("message")
+
+			Expect(order).To(Equal([]string{"first-replaced", "second"}))
+		})
+	})
+
+	Context("buffered sinks stay configurable after wrapping", func() {
+		It("lets the package-level SetMinLevel reach the default sink even when it's wrapped in NewBufferedSink", func() {
+			output := WrapUnsafeWriterWithLocks(&bytes.Buffer{})
+			AddSink(defaultSinkName, NewBufferedSink(NewJSONSink(output, LevelWarning), 8))
+			DeferCleanup(SetMinLevel, LevelWarning)
+
+			SetMinLevel(LevelInfo)
+
+			Expect(getSink(defaultSinkName).MinLevel()).To(Equal(LevelInfo))
+		})
+	})
+
+	Context("SetOutput as a shim", func() {
+		It("still only writes to the default sink at its configured level", func() {
+			output := WrapUnsafeWriterWithLocks(&bytes.Buffer{})
+			SetOutput(output)
+
+			ulog.Info("quiet")
+			ulog.Warning("loud")
+
+			Expect(output.String()).NotTo(ContainSubstring("quiet"))
+			Expect(output.String()).To(ContainSubstring("\"message\":\"loud\""))
+		})
+	})
+
+	Context("NewBufferedSink", func() {
+		It("drops entries once the buffer is full instead of blocking", func() {
+			release := make(chan struct{})
+			started := make(chan struct{})
+			blockOnce := sync.Once{}
+
+			slow := sinkFunc{
+				minLevel: LevelWarning,
+				write: func(Entry) error {
+					blockOnce.Do(func() {
+						close(started)
+						<-release
+					})
+					return nil
+				},
+			}
+
+			buffered := NewBufferedSink(slow, 1)
+			AddSink("slow", buffered)
+			DeferCleanup(func() {
+				close(release)
+				RemoveSink("slow")
+			})
+
+			ulog.Warning("first") // consumed, then blocks inside write
+			<-started
+			ulog.Warning("second") // fills the 1-entry buffer
+			ulog.Warning("third")  // buffer is full: dropped
+
+			Expect(buffered.(*bufferedSink).DroppedCount()).To(Equal(uint64(1)))
+		})
+	})
+})
+
+// sinkFunc adapts a plain Write func to the Sink interface for tests that
+// only care about one hook.
+type sinkFunc struct {
+	minLevel Level
+	write    func(Entry) error
+}
+
+func (s sinkFunc) MinLevel() Level     { return s.minLevel }
+func (s sinkFunc) Write(e Entry) error { return s.write(e) }
+func (s sinkFunc) Close() error        { return nil }
+
+var _ = Describe("logger.Sentry", Label("logger"), func() {
+	var (
+		capturedMu sync.Mutex
+		captured   []string
+	)
+	capturedMessages := func() []string {
+		capturedMu.Lock()
+		defer capturedMu.Unlock()
+		return append([]string(nil), captured...)
+	}
+
+	BeforeEach(func() {
+		capturedMu.Lock()
+		captured = nil
+		capturedMu.Unlock()
+		orig := sentryCapture
+		sentryCapture = func(err error, message string, extra map[string]interface{}) {
+			capturedMu.Lock()
+			defer capturedMu.Unlock()
+			captured = append(captured, message)
+		}
+		DeferCleanup(func() {
+			sentryCapture = orig
+		})
+	})
+
+	Context("default sink", func() {
+		It("is registered so CaptureSentryEvent(true) keeps working without opting in to a sink", func() {
+			Expect(getSink(sentrySinkName)).NotTo(BeNil())
+
+			ulog := NewOCMLogger(context.Background())
+			ulog.CaptureSentryEvent(true).Error(fmt.Errorf("boom"))
+			ulog.CaptureSentryEvent(false).Error(fmt.Errorf("not captured"))
+
+			// the built-in sentry sink is buffered, so delivery is asynchronous
+			Eventually(capturedMessages, "2s", "10ms").Should(Equal([]string{"boom"}))
+		})
+	})
+
+	Context("level gating", func() {
+		It("never forwards below LevelError regardless of the sink's own MinLevel", func() {
+			RemoveSink(sentrySinkName)
+			DeferCleanup(func() {
+				AddSink(sentrySinkName, NewSentrySink(LevelError))
+			})
+			AddSink("test-sentry-warn", NewSentrySink(LevelWarning))
+			DeferCleanup(func() {
+				RemoveSink("test-sentry-warn")
+			})
+
+			ulog := NewOCMLogger(context.Background())
+			ulog.CaptureSentryEvent(true).Warning("warn not captured")
+			ulog.CaptureSentryEvent(true).Error(fmt.Errorf("err captured"))
+
+			Expect(capturedMessages()).To(Equal([]string{"err captured"}))
+		})
+	})
+})
+
+var _ = Describe("logger.Sampling", Label("logger"), func() {
+	var output ThreadSafeBytesBuffer
+
+	BeforeEach(func() {
+		output = WrapUnsafeWriterWithLocks(&bytes.Buffer{})
+		SetOutput(output)
+		SetMinLevel(LevelInfo)
+		DeferCleanup(func() {
+			SetMinLevel(LevelWarning)
+			SetOutput(os.Stderr)
+		})
+	})
+
+	Context("Sampled()", func() {
+		It("emits only every-nth record from its call site", func() {
+			ulog := NewOCMLogger(context.Background()).Sampled(3)
+			for i := 0; i < 10; i++ {
+				ulog.Info("sampled %d", i)
+			}
+
+			lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+			Expect(lines).To(HaveLen(4)) // calls 1, 4, 7, 10
+		})
+
+		It("folds the suppressed count into the next emitted record", func() {
+			ulog := NewOCMLogger(context.Background()).Sampled(3)
+			for i := 0; i < 6; i++ {
+				ulog.Info("sampled %d", i)
+			}
+
+			lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+			Expect(lines).To(HaveLen(2))
+			Expect(lines[0]).NotTo(ContainSubstring("suppressed"))
+			Expect(lines[1]).To(ContainSubstring("\"suppressed\":2"))
+		})
+
+		It("tracks distinct call sites independently", func() {
+			ulog := NewOCMLogger(context.Background()).Sampled(2)
+			ulog.Info("call site A")
+			ulog.Info("call site B")
+
+			lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+			Expect(lines).To(HaveLen(2)) // each site's first call always emits
+		})
+	})
+
+	Context("FlushSuppressed()", func() {
+		It("reports a call site's trailing suppressed count on demand", func() {
+			ulog := NewOCMLogger(context.Background()).Sampled(100)
+			for i := 0; i < 5; i++ {
+				ulog.Info("trailing %d", i) // only the 1st emits; 4 left suppressed mid-window
+			}
+
+			FlushSuppressed()
+
+			Expect(output.String()).To(ContainSubstring("\"suppressed\":4"))
+		})
+
+		It("is a no-op for call sites with nothing suppressed", func() {
+			ulog := NewOCMLogger(context.Background()).Sampled(100)
+			ulog.Info("only call")
+			before := output.String()
+
+			FlushSuppressed()
+
+			Expect(output.String()).To(Equal(before))
+		})
+	})
+
+	Context("background flusher", func() {
+		It("eventually emits a suppressed summary without an explicit flush", func() {
+			SetSuppressedFlushInterval(20 * time.Millisecond)
+			DeferCleanup(func() {
+				SetSuppressedFlushInterval(10 * time.Second)
+			})
+
+			ulog := NewOCMLogger(context.Background()).Sampled(100)
+			for i := 0; i < 3; i++ {
+				ulog.Info("bg %d", i)
+			}
+
+			Eventually(output.String, "2s", "10ms").Should(ContainSubstring("suppressed"))
+		})
+	})
+
+	Context("RateLimited()", func() {
+		It("emits at most perSecond records from its call site", func() {
+			ulog := NewOCMLogger(context.Background()).RateLimited(2)
+			for i := 0; i < 5; i++ {
+				ulog.Info("limited %d", i)
+			}
+
+			lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+			Expect(len(lines)).To(BeNumerically("<=", 2))
+		})
+	})
+
+	Context("AdditionalCallLevelSkips()", func() {
+		It("attributes a wrapped call site past the wrapper, not inside it", func() {
+			ulog := NewOCMLogger(context.Background()).Sampled(2).AdditionalCallLevelSkips(1)
+			logFromWrapper := func() {
+				ulog.Info("wrapped")
+			}
+			logFromWrapper() // call site A
+			logFromWrapper() // call site B; would share A's sampler if attributed to the wrapper
+
+			lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+			Expect(lines).To(HaveLen(2)) // each site's first call always emits
+		})
+	})
+})
+
+var _ = Describe("logger.Level", Label("logger"), func() {
+	It("preserves the numeric values Level had before Trace/Debug existed", func() {
+		Expect(LevelInfo).To(Equal(Level(0)))
+		Expect(LevelWarning).To(Equal(Level(1)))
+		Expect(LevelError).To(Equal(Level(2)))
+		Expect(LevelFatal).To(Equal(Level(3)))
+	})
+
+	It("orders Trace and Debug below Info without disturbing the rest", func() {
+		Expect(LevelTrace < LevelDebug).To(BeTrue())
+		Expect(LevelDebug < LevelInfo).To(BeTrue())
+		Expect(LevelInfo < LevelWarning).To(BeTrue())
+		Expect(LevelWarning < LevelError).To(BeTrue())
+		Expect(LevelError < LevelFatal).To(BeTrue())
+	})
+})
+
+var _ = Describe("logger.Verbosity", Label("logger"), func() {
+	var output ThreadSafeBytesBuffer
+
+	BeforeEach(func() {
+		output = WrapUnsafeWriterWithLocks(&bytes.Buffer{})
+		AddSink(defaultSinkName, NewJSONSink(output, LevelTrace))
+		DeferCleanup(func() {
+			SetMinLevel(LevelWarning)
+			SetOutput(os.Stderr)
+			Expect(SetVerbosity("info")).To(Succeed())
+		})
+	})
+
+	Context("the default spec", func() {
+		It("suppresses Trace and Debug but still emits Info and above", func() {
+			ulog := NewOCMLogger(context.Background())
+			ulog.Trace("trace message")
+			ulog.Debug("debug message")
+			ulog.Info("info message")
+
+			Expect(output.String()).NotTo(ContainSubstring("trace message"))
+			Expect(output.String()).NotTo(ContainSubstring("debug message"))
+			Expect(output.String()).To(ContainSubstring("info message"))
+		})
+	})
+
+	Context("SetVerbosity", func() {
+		It("rejects a spec with an unrecognized level", func() {
+			Expect(SetVerbosity("nonsense")).NotTo(Succeed())
+		})
+
+		It("enables Trace for this call site's module without affecting the default", func() {
+			pc, _, _, _ := runtime.Caller(0)
+			module := deriveModule(pc)
+
+			Expect(SetVerbosity("info," + module + "=trace")).To(Succeed())
+
+			ulog := NewOCMLogger(context.Background())
+			ulog.Trace("now visible")
+
+			Expect(output.String()).To(ContainSubstring("now visible"))
+		})
+	})
+
+	Context("VerbosityHandler", func() {
+		It("GET returns the active spec and PUT replaces it", func() {
+			handler := VerbosityHandler()
+
+			Expect(SetVerbosity("warning")).To(Succeed())
+			req := httptest.NewRequest(http.MethodGet, "/verbosity", nil)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			Expect(rec.Body.String()).To(Equal("warning"))
+
+			req = httptest.NewRequest(http.MethodPut, "/verbosity", strings.NewReader("debug"))
+			rec = httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			Expect(rec.Code).To(Equal(http.StatusOK))
+
+			req = httptest.NewRequest(http.MethodGet, "/verbosity", nil)
+			rec = httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			Expect(rec.Body.String()).To(Equal("debug"))
+		})
+
+		It("rejects an invalid spec with 400", func() {
+			req := httptest.NewRequest(http.MethodPut, "/verbosity", strings.NewReader("garbage"))
+			rec := httptest.NewRecorder()
+			VerbosityHandler().ServeHTTP(rec, req)
+
+			Expect(rec.Code).To(Equal(http.StatusBadRequest))
+		})
+	})
 })
 
 var _ = Describe("logger chaos", Label("logger"), func() {
@@ -220,6 +738,19 @@ var _ = Describe("logger chaos", Label("logger"), func() {
 			}
 			waitForTestEnd.Wait()
 		})
+		It("With() is thread safe", func() {
+			parallelLog := NewOCMLogger(context.Background())
+
+			waitForTestEnd := sync.WaitGroup{}
+			for i := 0; i < maxChaos; i++ {
+				waitForTestEnd.Add(1)
+				go func(i int) {
+					defer waitForTestEnd.Done()
+					parallelLog.With("i", i).Info("With() %d", i)
+				}(i)
+			}
+			waitForTestEnd.Wait()
+		})
 		It("CaptureSentryEvent() is thread safe", func() {
 			parallelLog := NewOCMLogger(context.Background())
 
@@ -247,6 +778,21 @@ var _ = Describe("logger chaos", Label("logger"), func() {
 			}
 			waitForTestEnd.Wait()
 		})
+		It("Sampled()/RateLimited() are thread safe", func() {
+			sampledLog := NewOCMLogger(context.Background()).Sampled(10)
+			limitedLog := NewOCMLogger(context.Background()).RateLimited(1000000)
+
+			waitForTestEnd := sync.WaitGroup{}
+			for i := 0; i < maxChaos; i++ {
+				waitForTestEnd.Add(1)
+				go func(i int) {
+					defer waitForTestEnd.Done()
+					sampledLog.Info("Sampled() %d", i)
+					limitedLog.Info("RateLimited() %d", i)
+				}(i)
+			}
+			waitForTestEnd.Wait()
+		})
 		It("Contextual() Lots of extras and an error for fun", func() {
 			parallelLog := NewOCMLogger(context.Background())
 			maxExtras := 100
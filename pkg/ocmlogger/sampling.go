@@ -0,0 +1,201 @@
+package ocmlogger
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// throttle applies l's Sampled/RateLimited configuration (whichever is set;
+// Sampled and RateLimited are mutually exclusive, see their doc comments) and
+// reports whether the current call should be emitted, along with how many
+// prior calls from the same call site were suppressed since the last one
+// that was. Call sites are identified by file:line via runtime.Caller, honoring
+// AdditionalCallLevelSkips so a logger wrapped in a helper still attributes
+// to the helper's caller rather than to the helper itself.
+func (l ocmLogger) throttle(level Level) (emit bool, suppressed uint64) {
+	key := callerKey(l.additionalCallLevelSkips)
+
+	if l.sampleEvery > 0 {
+		return getSampler(key, uint64(l.sampleEvery), level).check()
+	}
+	return getRateLimiter(key, uint64(l.rateLimitPerSecond), level).check(time.Now().Unix())
+}
+
+// callSiteBaseSkip is how many stack frames separate callerKey's call to
+// runtime.Caller from the user code that ultimately invoked Info/Warning/
+// Error/Contextual().Info/... : callerKey -> throttle -> log -> the
+// Info/Warning/Error wrapper -> the caller we want to attribute to.
+const callSiteBaseSkip = 4
+
+// callerKey identifies the source line additionalSkips frames above the
+// logging call itself, so two distinct call sites never share a sampler or
+// rate limiter even if they log an identical message.
+func callerKey(additionalSkips int) string {
+	_, file, line, ok := runtime.Caller(callSiteBaseSkip + additionalSkips)
+	if !ok {
+		return "unknown"
+	}
+	return fmt.Sprintf("%s:%d", file, line)
+}
+
+// callSiteSampler implements "emit 1-in-every" for a single call site. count
+// and suppressed are shared across every goroutine logging from that site,
+// so they're updated with atomics rather than a mutex to stay cheap under
+// heavy concurrent logging. level is fixed at creation (a given call site
+// always logs at the same level) and is only read by the periodic flusher.
+type callSiteSampler struct {
+	every      uint64
+	level      Level
+	count      uint64
+	suppressed uint64
+}
+
+func (s *callSiteSampler) check() (emit bool, suppressed uint64) {
+	n := atomic.AddUint64(&s.count, 1)
+	if (n-1)%s.every == 0 {
+		return true, atomic.SwapUint64(&s.suppressed, 0)
+	}
+	atomic.AddUint64(&s.suppressed, 1)
+	return false, 0
+}
+
+// callSiteRateLimiter implements "emit at most perSecond-per-second" for a
+// single call site. It is a best-effort limiter: the window rollover is not
+// linearizable with concurrent increments, so perSecond may occasionally be
+// exceeded by a handful of records under heavy contention, but it never
+// drifts beyond that or loses track of the suppressed count.
+type callSiteRateLimiter struct {
+	perSecond  uint64
+	level      Level
+	windowSec  int64
+	count      uint64
+	suppressed uint64
+}
+
+func (r *callSiteRateLimiter) check(nowSec int64) (emit bool, suppressed uint64) {
+	for {
+		window := atomic.LoadInt64(&r.windowSec)
+		if window == nowSec {
+			break
+		}
+		if atomic.CompareAndSwapInt64(&r.windowSec, window, nowSec) {
+			atomic.StoreUint64(&r.count, 0)
+			break
+		}
+	}
+
+	n := atomic.AddUint64(&r.count, 1)
+	if n <= r.perSecond {
+		return true, atomic.SwapUint64(&r.suppressed, 0)
+	}
+	atomic.AddUint64(&r.suppressed, 1)
+	return false, 0
+}
+
+// samplers/rateLimiters memoize one entry per call site, the same lazy
+// sync.Map pattern verbosity.go's modulePCCache uses: creation (the rare
+// path, via LoadOrStore) is the only point that could contend, and every
+// subsequent check() on an already-created entry only touches that entry's
+// own atomics, never a shared lock - unlike a mutex-guarded map, which would
+// serialize every call from every hot-loop call site on one lock.
+var (
+	samplers     sync.Map // map[string]*callSiteSampler
+	rateLimiters sync.Map // map[string]*callSiteRateLimiter
+)
+
+func getSampler(key string, every uint64, level Level) *callSiteSampler {
+	if v, ok := samplers.Load(key); ok {
+		return v.(*callSiteSampler)
+	}
+	v, _ := samplers.LoadOrStore(key, &callSiteSampler{every: every, level: level})
+	ensureSuppressedFlusherStarted()
+	return v.(*callSiteSampler)
+}
+
+func getRateLimiter(key string, perSecond uint64, level Level) *callSiteRateLimiter {
+	if v, ok := rateLimiters.Load(key); ok {
+		return v.(*callSiteRateLimiter)
+	}
+	v, _ := rateLimiters.LoadOrStore(key, &callSiteRateLimiter{perSecond: perSecond, level: level})
+	ensureSuppressedFlusherStarted()
+	return v.(*callSiteRateLimiter)
+}
+
+// suppressedFlushIntervalNanos is how often (in nanoseconds) the background
+// flusher started by ensureSuppressedFlusherStarted emits a summary record
+// for any call site with a nonzero suppressed count. It's read by that
+// goroutine on every iteration and may be overridden concurrently (tests do,
+// so they don't have to wait out a production-sized interval), so it's
+// accessed through atomic.Load/StoreInt64 like every other shared counter in
+// this file rather than as a plain var.
+var suppressedFlushIntervalNanos = int64(10 * time.Second)
+
+func suppressedFlushInterval() time.Duration {
+	return time.Duration(atomic.LoadInt64(&suppressedFlushIntervalNanos))
+}
+
+// SetSuppressedFlushInterval changes how often the background flusher emits
+// a summary for call sites with a nonzero suppressed count. It exists mainly
+// so tests don't have to wait out the 10-second default; production code
+// should normally leave it alone.
+func SetSuppressedFlushInterval(d time.Duration) {
+	atomic.StoreInt64(&suppressedFlushIntervalNanos, int64(d))
+}
+
+var startSuppressedFlusher sync.Once
+
+// suppressedFlushPollInterval is the granularity at which the background
+// flusher re-reads suppressedFlushIntervalNanos. It's independent of (and
+// much shorter than) the flush interval itself, so a change made via
+// SetSuppressedFlushInterval takes effect within one tick instead of only
+// after whatever sleep the goroutine happened to already be in.
+const suppressedFlushPollInterval = 50 * time.Millisecond
+
+// ensureSuppressedFlusherStarted lazily starts the single background
+// goroutine that periodically calls FlushSuppressed, so a process that never
+// calls Sampled/RateLimited never pays for it. Once started it runs for the
+// lifetime of the process, the same trade-off bufferedSink's consumer
+// goroutine makes for as long as it is registered.
+func ensureSuppressedFlusherStarted() {
+	startSuppressedFlusher.Do(func() {
+		go func() {
+			lastFlush := time.Now()
+			for {
+				time.Sleep(suppressedFlushPollInterval)
+				if time.Since(lastFlush) >= suppressedFlushInterval() {
+					FlushSuppressed()
+					lastFlush = time.Now()
+				}
+			}
+		}()
+	})
+}
+
+// FlushSuppressed emits a "suppressed":<n> summary record (at the level that
+// call site normally logs at) for every Sampled/RateLimited call site with a
+// nonzero suppressed count since its last emitted record or flush, then
+// resets that count to zero. It runs automatically every
+// suppressedFlushInterval once any call site starts sampling or rate
+// limiting, so a call site that stops logging mid-window (its retry loop
+// succeeded, or gave up) still reports its trailing suppressed count instead
+// of losing it silently; callers that want that window reported immediately,
+// e.g. during graceful shutdown, can call it directly.
+func FlushSuppressed() {
+	samplers.Range(func(_, v interface{}) bool {
+		s := v.(*callSiteSampler)
+		if n := atomic.SwapUint64(&s.suppressed, 0); n > 0 {
+			publish(Entry{Level: s.level, Message: "suppressed log records", Extra: map[string]interface{}{"suppressed": n}})
+		}
+		return true
+	})
+	rateLimiters.Range(func(_, v interface{}) bool {
+		r := v.(*callSiteRateLimiter)
+		if n := atomic.SwapUint64(&r.suppressed, 0); n > 0 {
+			publish(Entry{Level: r.level, Message: "suppressed log records", Extra: map[string]interface{}{"suppressed": n}})
+		}
+		return true
+	})
+}
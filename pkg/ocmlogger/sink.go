@@ -0,0 +1,341 @@
+package ocmlogger
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Entry is the record handed to every Sink for a single log call.
+type Entry struct {
+	Level   Level                  `json:"level"`
+	Error   string                 `json:"error,omitempty"`
+	Message string                 `json:"message"`
+	Extra   map[string]interface{} `json:"Extra,omitempty"`
+
+	// err and captureSentry are not serialized; they exist so the built-in
+	// Sentry sink can fold in the CaptureSentryEvent gating that used to
+	// live directly in ocmLogger.log.
+	err           error
+	captureSentry bool
+}
+
+// Sink receives every Entry at or above its MinLevel. Sinks registered via
+// AddSink are written to synchronously, in the goroutine that produced the
+// log call, in registration order; a sink that is occasionally slow (a
+// remote endpoint, a disk-bound file, ...) should be wrapped with
+// NewBufferedSink so it cannot stall either the caller or fan-out to other
+// sinks.
+type Sink interface {
+	// Write persists or forwards entry. It may be called concurrently by
+	// multiple goroutines and must serialize its own access to any shared
+	// resource (see jsonSink/textSink for the pattern this package uses).
+	Write(entry Entry) error
+	// MinLevel is the lowest level this sink wants to receive.
+	MinLevel() Level
+	// Close releases any resources held by the sink (files, network
+	// connections, ...). It is called once, when the sink is removed or
+	// replaced.
+	Close() error
+}
+
+// defaultSinkName is the name SetOutput manages; replacing it is how
+// SetOutput stays a thin shim over the sink registry.
+const defaultSinkName = "default"
+
+// sentrySinkName is the name the built-in Sentry sink is registered under,
+// so CaptureSentryEvent(true) keeps working unconditionally, the same way it
+// did before Sentry forwarding moved behind the Sink interface. Operators
+// that don't want Sentry involved at all can RemoveSink(sentrySinkName); the
+// sink itself is already a no-op until sentry.CurrentHub() has a client.
+const sentrySinkName = "sentry"
+
+// defaultSinkBufferSize bounds how many entries the built-in default and
+// sentry sinks (see init below) queue before a burst starts getting dropped
+// instead of blocking the producer; see bufferedSink's doc comment for why
+// dropping, not blocking, is the right failure mode here.
+const defaultSinkBufferSize = 1024
+
+var (
+	sinksMu sync.RWMutex
+	sinks   = map[string]Sink{}
+	// sinkOrder preserves AddSink registration order, since Go map iteration
+	// order is randomized and publish must fan out in the order Sink's doc
+	// comment promises.
+	sinkOrder []string
+)
+
+func init() {
+	AddSink(defaultSinkName, NewBufferedSink(NewJSONSink(os.Stderr, LevelWarning), defaultSinkBufferSize))
+	AddSink(sentrySinkName, NewBufferedSink(NewSentrySink(LevelError), defaultSinkBufferSize))
+}
+
+// AddSink registers s under name, closing and replacing any sink already
+// registered under that name. Replacing an existing name keeps its original
+// position in registration order; only a genuinely new name is appended.
+func AddSink(name string, s Sink) {
+	sinksMu.Lock()
+	old, existed := sinks[name]
+	sinks[name] = s
+	if !existed {
+		sinkOrder = append(sinkOrder, name)
+	}
+	sinksMu.Unlock()
+
+	if existed {
+		_ = old.Close()
+	}
+}
+
+// RemoveSink closes and unregisters the sink registered under name, if any.
+func RemoveSink(name string) {
+	sinksMu.Lock()
+	s, ok := sinks[name]
+	delete(sinks, name)
+	if ok {
+		for i, n := range sinkOrder {
+			if n == name {
+				sinkOrder = append(sinkOrder[:i], sinkOrder[i+1:]...)
+				break
+			}
+		}
+	}
+	sinksMu.Unlock()
+
+	if ok {
+		_ = s.Close()
+	}
+}
+
+func getSink(name string) Sink {
+	sinksMu.RLock()
+	defer sinksMu.RUnlock()
+	return sinks[name]
+}
+
+// publish fans e out, synchronously, to every registered sink whose
+// MinLevel it meets, in registration order.
+func publish(e Entry) {
+	sinksMu.RLock()
+	defer sinksMu.RUnlock()
+	for _, name := range sinkOrder {
+		s := sinks[name]
+		if e.Level < s.MinLevel() {
+			continue
+		}
+		_ = s.Write(e)
+	}
+}
+
+// minLevelSetter is implemented by sinks whose MinLevel can be changed after
+// construction, e.g. by the package-level SetMinLevel. bufferedSink forwards
+// through to it so wrapping a sink for non-blocking delivery (as the default
+// and sentry sinks are, see init above) doesn't hide it behind a type
+// assertion that only matches the unwrapped concrete type.
+type minLevelSetter interface {
+	SetMinLevel(Level)
+}
+
+// jsonSink is the same JSON-lines behavior the logger has always had,
+// wrapped behind the Sink interface. SetOutput manages one of these under
+// defaultSinkName.
+type jsonSink struct {
+	w     io.Writer
+	wmu   sync.Mutex
+	level int32
+}
+
+// NewJSONSink returns a Sink that writes entry as a line of JSON to w,
+// emitting only entries at or above minLevel.
+func NewJSONSink(w io.Writer, minLevel Level) Sink {
+	s := &jsonSink{w: w}
+	s.SetMinLevel(minLevel)
+	return s
+}
+
+func (s *jsonSink) MinLevel() Level {
+	return Level(atomic.LoadInt32(&s.level))
+}
+
+// SetMinLevel changes the level threshold of an already-installed JSON
+// sink, e.g. as used internally by the package-level SetMinLevel.
+func (s *jsonSink) SetMinLevel(minLevel Level) {
+	atomic.StoreInt32(&s.level, int32(minLevel))
+}
+
+func (s *jsonSink) Write(e Entry) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+	_, err = s.w.Write(b)
+	return err
+}
+
+func (s *jsonSink) Close() error {
+	return nil
+}
+
+// textSink renders entries as a single human-readable line, e.g.:
+//
+//	WARNING: message cluster_id=abc123 request_id=req-1
+type textSink struct {
+	w     io.Writer
+	wmu   sync.Mutex
+	level int32
+}
+
+// NewTextSink returns a Sink that writes entry as a human-readable line to
+// w, emitting only entries at or above minLevel.
+func NewTextSink(w io.Writer, minLevel Level) Sink {
+	s := &textSink{w: w}
+	atomic.StoreInt32(&s.level, int32(minLevel))
+	return s
+}
+
+func (s *textSink) MinLevel() Level {
+	return Level(atomic.LoadInt32(&s.level))
+}
+
+func (s *textSink) Write(e Entry) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s: %s", strings.ToUpper(e.Level.String()), e.Message)
+	if e.Error != "" {
+		fmt.Fprintf(&b, " error=%q", e.Error)
+	}
+
+	keys := make([]string, 0, len(e.Extra))
+	for k := range e.Extra {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(&b, " %s=%v", k, e.Extra[k])
+	}
+	b.WriteByte('\n')
+
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+	_, err := io.WriteString(s.w, b.String())
+	return err
+}
+
+func (s *textSink) Close() error {
+	return nil
+}
+
+// sentrySink forwards entries to Sentry, gated by whether the logger that
+// produced them had CaptureSentryEvent(true) set - the same opt-in the
+// logger has always offered, now expressed as just another sink. Regardless
+// of MinLevel, it never forwards anything below LevelError: CaptureSentryEvent
+// has only ever meant "send this error to Sentry," and MinLevel exists so a
+// sink can additionally be throttled on top of that, not to broaden it to
+// other levels.
+type sentrySink struct {
+	level int32
+}
+
+// NewSentrySink returns a Sink that forwards LevelError entries (and only
+// LevelError entries, regardless of minLevel) to Sentry, provided the
+// producing logger called CaptureSentryEvent(true); minLevel can only raise
+// the floor above LevelError, e.g. to exclude Sentry forwarding entirely by
+// passing a level above LevelFatal. It is a no-op when no Sentry client has
+// been configured for the process.
+func NewSentrySink(minLevel Level) Sink {
+	s := &sentrySink{}
+	atomic.StoreInt32(&s.level, int32(minLevel))
+	return s
+}
+
+func (s *sentrySink) MinLevel() Level {
+	return Level(atomic.LoadInt32(&s.level))
+}
+
+func (s *sentrySink) Write(e Entry) error {
+	if e.Level != LevelError || !e.captureSentry {
+		return nil
+	}
+	sentryCapture(e.err, e.Message, e.Extra)
+	return nil
+}
+
+func (s *sentrySink) Close() error {
+	return nil
+}
+
+// bufferedSink decorates another Sink so that Write is handled by a single
+// background goroutine draining a bounded queue, instead of running inline
+// in the logging goroutine. If the queue is full when an entry arrives, the
+// entry is dropped and counted (see DroppedCount) rather than blocking the
+// caller - this is the policy to reach for when a sink is occasionally slow
+// and must not stall either its caller or fan-out to other sinks.
+type bufferedSink struct {
+	sink    Sink
+	entries chan Entry
+	done    chan struct{}
+	dropped uint64
+}
+
+// NewBufferedSink wraps sink so its Write calls happen asynchronously on a
+// dedicated goroutine, buffering up to bufferSize entries. Once the buffer
+// is full, further entries are dropped (and counted) until the goroutine
+// catches up.
+func NewBufferedSink(sink Sink, bufferSize int) Sink {
+	b := &bufferedSink{
+		sink:    sink,
+		entries: make(chan Entry, bufferSize),
+		done:    make(chan struct{}),
+	}
+	go b.run()
+	return b
+}
+
+func (b *bufferedSink) run() {
+	defer close(b.done)
+	for e := range b.entries {
+		_ = b.sink.Write(e)
+	}
+}
+
+func (b *bufferedSink) MinLevel() Level {
+	return b.sink.MinLevel()
+}
+
+// SetMinLevel forwards to the wrapped sink if it supports changing its
+// MinLevel after construction, and is a no-op otherwise.
+func (b *bufferedSink) SetMinLevel(minLevel Level) {
+	if s, ok := b.sink.(minLevelSetter); ok {
+		s.SetMinLevel(minLevel)
+	}
+}
+
+func (b *bufferedSink) Write(e Entry) error {
+	select {
+	case b.entries <- e:
+		return nil
+	default:
+		atomic.AddUint64(&b.dropped, 1)
+		return nil
+	}
+}
+
+// DroppedCount returns how many entries have been dropped because the
+// buffer was full when they arrived.
+func (b *bufferedSink) DroppedCount() uint64 {
+	return atomic.LoadUint64(&b.dropped)
+}
+
+func (b *bufferedSink) Close() error {
+	close(b.entries)
+	<-b.done
+	return b.sink.Close()
+}
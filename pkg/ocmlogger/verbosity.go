@@ -0,0 +1,200 @@
+package ocmlogger
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// verbosity is the process-wide, glog-style level filter consulted by every
+// log call before a record is even built: a default level plus per-module
+// overrides, set via SetVerbosity and read via VerbosityHandler.
+var verbosity = &verbositySpec{def: LevelInfo}
+
+type verbositySpec struct {
+	mu      sync.RWMutex
+	def     Level
+	modules map[string]Level
+}
+
+func (v *verbositySpec) levelFor(module string) Level {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	if module != "" {
+		if l, ok := v.modules[module]; ok {
+			return l
+		}
+	}
+	return v.def
+}
+
+// String renders the spec the way SetVerbosity expects to receive it back,
+// e.g. "info,ocm/clusters=debug,ocm/quota=trace".
+func (v *verbositySpec) String() string {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	parts := []string{v.def.String()}
+	modules := make([]string, 0, len(v.modules))
+	for m := range v.modules {
+		modules = append(modules, m)
+	}
+	sort.Strings(modules)
+	for _, m := range modules {
+		parts = append(parts, fmt.Sprintf("%s=%s", m, v.modules[m]))
+	}
+	return strings.Join(parts, ",")
+}
+
+// SetVerbosity replaces the active verbosity spec. spec is a comma-separated
+// list of a default level (e.g. "info") and zero or more "module=level"
+// overrides (e.g. "ocm/clusters=debug"), in any order - the same shape glog
+// uses for its --vmodule flag. A module with no override falls back to the
+// default. An empty or all-whitespace spec is rejected; callers that want to
+// reset to the default should pass "info".
+func SetVerbosity(spec string) error {
+	def := LevelInfo
+	defSet := false
+	modules := map[string]Level{}
+
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		if module, levelStr, ok := strings.Cut(part, "="); ok {
+			level, ok := parseLevel(levelStr)
+			if !ok {
+				return fmt.Errorf("ocmlogger: invalid verbosity level %q in %q", levelStr, part)
+			}
+			modules[strings.TrimSpace(module)] = level
+			continue
+		}
+
+		level, ok := parseLevel(part)
+		if !ok {
+			return fmt.Errorf("ocmlogger: invalid verbosity level %q", part)
+		}
+		def, defSet = level, true
+	}
+
+	if !defSet && len(modules) == 0 {
+		return fmt.Errorf("ocmlogger: empty verbosity spec")
+	}
+	if !defSet {
+		def = verbosity.levelFor("")
+	}
+
+	verbosity.mu.Lock()
+	verbosity.def = def
+	verbosity.modules = modules
+	verbosity.mu.Unlock()
+	return nil
+}
+
+func parseLevel(s string) (Level, bool) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "trace":
+		return LevelTrace, true
+	case "debug":
+		return LevelDebug, true
+	case "info":
+		return LevelInfo, true
+	case "warning", "warn":
+		return LevelWarning, true
+	case "error":
+		return LevelError, true
+	case "fatal":
+		return LevelFatal, true
+	default:
+		return 0, false
+	}
+}
+
+// VerbosityHandler returns an http.Handler operators can mount on an admin
+// port to inspect or change the active verbosity spec at runtime, without a
+// restart: GET returns the current spec, PUT (or POST) replaces it with the
+// request body, same syntax as SetVerbosity.
+func VerbosityHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			_, _ = io.WriteString(w, verbosity.String())
+		case http.MethodPut, http.MethodPost:
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if err := SetVerbosity(strings.TrimSpace(string(body))); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+// verbosityCallBaseSkip mirrors callSiteBaseSkip in sampling.go: moduleKey is
+// called from the same depth within log() (log -> verbosityAllows ->
+// moduleKey -> runtime.Caller) as callerKey is from throttle.
+const verbosityCallBaseSkip = 4
+
+// verbosityAllows reports whether level should be logged from the call site
+// additionalSkips frames above the logging call, given the active
+// verbosity spec.
+func verbosityAllows(level Level, additionalSkips int) bool {
+	return level >= verbosity.levelFor(moduleKey(additionalSkips))
+}
+
+// modulePCCache memoizes deriveModule per call-site PC, since
+// runtime.FuncForPC and the string slicing below are too costly to redo on
+// every single log call once the answer for a given call site is known.
+var modulePCCache sync.Map // map[uintptr]string
+
+func moduleKey(additionalSkips int) string {
+	pc, _, _, ok := runtime.Caller(verbosityCallBaseSkip + additionalSkips)
+	if !ok {
+		return ""
+	}
+
+	if v, ok := modulePCCache.Load(pc); ok {
+		return v.(string)
+	}
+	mod := deriveModule(pc)
+	modulePCCache.Store(pc, mod)
+	return mod
+}
+
+// deriveModule turns a PC's fully-qualified function name, e.g.
+// "github.com/org/ocm-service/ocm/clusters.(*Reconciler).Sync", into the
+// short module name operators write in a verbosity spec, e.g.
+// "ocm/clusters": the package path, trimmed to its last two slash-delimited
+// segments.
+func deriveModule(pc uintptr) string {
+	fn := runtime.FuncForPC(pc)
+	if fn == nil {
+		return ""
+	}
+
+	pkgPath := fn.Name()
+	if idx := strings.LastIndex(pkgPath, "/"); idx >= 0 {
+		if dot := strings.Index(pkgPath[idx+1:], "."); dot >= 0 {
+			pkgPath = pkgPath[:idx+1+dot]
+		}
+	} else if dot := strings.Index(pkgPath, "."); dot >= 0 {
+		pkgPath = pkgPath[:dot]
+	}
+
+	segments := strings.Split(pkgPath, "/")
+	if len(segments) > 2 {
+		segments = segments[len(segments)-2:]
+	}
+	return strings.Join(segments, "/")
+}